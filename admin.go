@@ -0,0 +1,182 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultAdminListLimit = 100
+
+var adminSnippetTemplate = template.Must(template.New("admin-snippet").Parse(`<!DOCTYPE html>
+<h1>{{.Key}}</h1>
+<p>Created at: {{.CreatedAt}}</p>
+<pre>{{.Content}}</pre>
+<form method="POST" action="/admin/snippet/{{.Key}}/delete">
+<button type="submit">Delete</button>
+</form>
+`))
+
+// adminEmails is the allowlist of Google account emails allowed into
+// /admin/, taken from SNIPPETS_ADMIN_EMAILS (a comma-separated list).
+func adminEmails() []string {
+	v := os.Getenv("SNIPPETS_ADMIN_EMAILS")
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// isAdmin reports whether r identifies an allowed admin. Like admingolangorg,
+// it relies on Identity-Aware Proxy, but it doesn't trust IAP's forwarded
+// X-Goog-Authenticated-User-Email header on its own: that header is just a
+// string a direct client could set itself, so isAdmin instead verifies the
+// signed X-Goog-IAP-JWT-Assertion header against IAP's public keys and the
+// configured audience, and trusts only the email that verification yields.
+func isAdmin(r *http.Request) bool {
+	assertion := r.Header.Get("X-Goog-IAP-JWT-Assertion")
+	if assertion == "" {
+		return false
+	}
+	email, err := verifyIAPAssertion(assertion)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range adminEmails() {
+		if strings.EqualFold(strings.TrimSpace(allowed), email) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAdmin returns an http.HandlerFunc serving the /admin/ subtree, which
+// lets operators list, inspect, and delete stored snippets.
+func handleAdmin(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		ctx := r.Context()
+
+		switch {
+		case r.URL.Path == "/admin/snippets":
+			listAdminSnippets(ctx, store, w, r)
+		case strings.HasSuffix(r.URL.Path, "/delete"):
+			key := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/snippet/"), "/delete")
+			deleteAdminSnippet(ctx, store, w, r, key)
+		case strings.HasPrefix(r.URL.Path, "/admin/snippet/"):
+			key := strings.TrimPrefix(r.URL.Path, "/admin/snippet/")
+			getAdminSnippet(ctx, store, w, r, key)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func listAdminSnippets(ctx context.Context, store Store, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(s), s)
+		return
+	}
+
+	limit := defaultAdminListLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	metas, nextCursor, err := store.ListSnippets(ctx, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		msg := fmt.Sprintf("Could not list snippets: %v", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, `{"snippets":[`)
+	for i, m := range metas {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprintf(w, `{"key":%q,"createdAt":%q,"size":%d}`, m.Key, m.CreatedAt.Format(`2006-01-02T15:04:05Z07:00`), m.Size)
+	}
+	fmt.Fprintf(w, `],"nextCursor":%q}`, nextCursor)
+}
+
+func getAdminSnippet(ctx context.Context, store Store, w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != http.MethodGet {
+		s := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(s), s)
+		return
+	}
+
+	s := &Snippet{}
+	ok, err := store.GetSnippet(ctx, key, s)
+	if err != nil {
+		msg := fmt.Sprintf("Could not retrieve data: %v", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, err := decompressContent(s.Content, s.Encoding)
+	if err != nil {
+		msg := fmt.Sprintf("Could not decode data: %v", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	adminSnippetTemplate.Execute(w, struct {
+		Key       string
+		CreatedAt string
+		Content   string
+	}{
+		Key:       key,
+		CreatedAt: s.CreatedAt.Format(`2006-01-02T15:04:05Z07:00`),
+		Content:   string(content),
+	})
+}
+
+func deleteAdminSnippet(ctx context.Context, store Store, w http.ResponseWriter, r *http.Request, key string) {
+	if r.Method != http.MethodPost {
+		s := http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(s), s)
+		return
+	}
+
+	if err := store.DeleteSnippet(ctx, key); err != nil {
+		msg := fmt.Sprintf("Could not delete snippet: %v", err)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
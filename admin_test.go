@@ -0,0 +1,70 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminListAndDeleteSnippet(t *testing.T) {
+	store := NewMemoryStore()
+	h := handleSnippets(store)
+	admin := handleAdmin(store)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/", strings.NewReader("admin test content")))
+	key := w.Body.String()
+
+	// Without an admin user, the admin subtree is forbidden.
+	w = httptest.NewRecorder()
+	admin(w, httptest.NewRequest("GET", "/admin/snippets", nil))
+	if got, want := w.Code, 403; got != want {
+		t.Fatalf("GET /admin/snippets without admin: got %d, want %d", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	admin(w, httptest.NewRequest("POST", "/admin/snippet/"+key+"/delete", nil))
+	if got, want := w.Code, 403; got != want {
+		t.Fatalf("POST delete without admin: got %d, want %d", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/"+key, nil))
+	if got, want := w.Code, 200; got != want {
+		t.Fatalf("snippet should still exist: got %d, want %d", got, want)
+	}
+
+	// An allowed admin identity, as IAP would attach it, can delete the
+	// snippet.
+	const aud = "/projects/123456789/global/backendServices/987654321"
+	t.Setenv("SNIPPETS_ADMIN_EMAILS", "admin@example.com")
+	t.Setenv("SNIPPETS_IAP_AUDIENCE", aud)
+	assertion := newTestIAPAssertion(t, aud, "admin@example.com")
+	r := httptest.NewRequest("POST", "/admin/snippet/"+key+"/delete", nil)
+	r.Header.Set("X-Goog-IAP-JWT-Assertion", assertion)
+	w = httptest.NewRecorder()
+	admin(w, r)
+	if got, want := w.Code, 200; got != want {
+		t.Fatalf("POST delete as admin: got %d, want %d", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/"+key, nil))
+	if got, want := w.Code, 404; got != want {
+		t.Fatalf("snippet should be gone after delete: got %d, want %d", got, want)
+	}
+}
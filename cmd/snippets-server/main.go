@@ -0,0 +1,66 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command snippets-server serves the Ebiten Playground's snippet storage
+// API, backed by Cloud Datastore and a Redis-compatible cache (e.g. Cloud
+// Memorystore). It replaces the classic App Engine deployment of this
+// package, so it can run on Cloud Run, GKE, or any other Go host.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"cloud.google.com/go/datastore"
+	"github.com/redis/go-redis/v9"
+
+	snippets "github.com/hajimehoshi/ebiten-playground-storage"
+)
+
+func main() {
+	projectID := flag.String("project", os.Getenv("GOOGLE_CLOUD_PROJECT"), "Cloud Datastore project ID")
+	redisAddr := flag.String("redis-addr", os.Getenv("REDIS_ADDR"), "address of the Redis (Cloud Memorystore) instance")
+	flag.Parse()
+
+	if *projectID == "" {
+		log.Fatal("snippets-server: -project (or GOOGLE_CLOUD_PROJECT) must be set")
+	}
+	if *redisAddr == "" {
+		log.Fatal("snippets-server: -redis-addr (or REDIS_ADDR) must be set")
+	}
+
+	ctx := context.Background()
+
+	ds, err := datastore.NewClient(ctx, *projectID)
+	if err != nil {
+		log.Fatalf("snippets-server: creating Datastore client: %v", err)
+	}
+	defer ds.Close()
+
+	cache := redis.NewClient(&redis.Options{Addr: *redisAddr})
+	defer cache.Close()
+
+	mux := http.NewServeMux()
+	snippets.RegisterHandlers(mux, ds, cache)
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Printf("snippets-server: listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
@@ -0,0 +1,94 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingNone = ""
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+)
+
+// compressionThreshold is the minimum content size worth compressing; below
+// it, the gzip/zstd frame overhead outweighs the savings.
+const compressionThreshold = 1024
+
+// storageEncoding is the encoding used for newly stored snippets that are
+// large enough to compress. It defaults to gzip, which also lets
+// getSnippets stream stored bytes straight through to clients that send
+// "Accept-Encoding: gzip". Set it to encodingZstd for a better ratio at the
+// cost of that fast path.
+var storageEncoding = encodingGzip
+
+// compressForStorage compresses content for storage if it's large enough to
+// be worth it, returning the bytes to store and the Encoding to record
+// alongside them.
+func compressForStorage(content []byte) (data []byte, encoding string, err error) {
+	if len(content) < compressionThreshold {
+		return content, encodingNone, nil
+	}
+
+	switch storageEncoding {
+	case encodingZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, "", err
+		}
+		defer enc.Close()
+		return enc.EncodeAll(content, nil), encodingZstd, nil
+	default:
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(content); err != nil {
+			return nil, "", err
+		}
+		if err := gw.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), encodingGzip, nil
+	}
+}
+
+// decompressContent reverses compressForStorage.
+func decompressContent(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case encodingNone:
+		return data, nil
+	case encodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case encodingZstd:
+		d, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer d.Close()
+		return d.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("snippets: unknown encoding %q", encoding)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressForStorageBelowThreshold(t *testing.T) {
+	content := []byte("short")
+	data, encoding, err := compressForStorage(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoding != encodingNone {
+		t.Fatalf("encoding: got %q, want %q", encoding, encodingNone)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("data: got %q, want %q", data, content)
+	}
+}
+
+func TestCompressAndDecompressRoundTrip(t *testing.T) {
+	content := []byte(strings.Repeat("round trip me ", 200))
+
+	data, encoding, err := compressForStorage(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoding == encodingNone {
+		t.Fatal("expected content above the threshold to be compressed")
+	}
+	if bytes.Equal(data, content) {
+		t.Fatal("compressed data should differ from the original")
+	}
+
+	got, err := decompressContent(data, encoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("decompressed: got %q, want %q", got, content)
+	}
+}
+
+func TestDecompressUnencodedIsIdentity(t *testing.T) {
+	content := []byte("raw bytes from before compression existed")
+	got, err := decompressContent(content, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("got %q, want %q", got, content)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// iapPublicKeyURL serves the ECDSA public keys IAP signs its JWTs with, as a
+// JWK set. See
+// https://cloud.google.com/iap/docs/signed-headers-howto#verifying_the_jwt_payload.
+// Tests point this at a local server instead of fetching the real endpoint.
+var iapPublicKeyURL = "https://www.gstatic.com/iap/verify/public_key-jwk"
+
+const iapIssuer = "https://cloud.google.com/iap"
+
+// iapAudience is the audience IAP stamps into the JWTs it issues for this
+// service, taken from SNIPPETS_IAP_AUDIENCE (e.g.
+// "/projects/PROJECT_NUMBER/global/backendServices/SERVICE_ID"). isAdmin
+// rejects every request if this isn't set, so the audience check can't be
+// silently skipped by a misconfigured deployment.
+func iapAudience() string {
+	return os.Getenv("SNIPPETS_IAP_AUDIENCE")
+}
+
+// iapKeySet caches IAP's public signing keys, fetched from iapPublicKeyURL.
+type iapKeySet struct {
+	mu      sync.Mutex
+	keys    map[string]*ecdsa.PublicKey
+	fetched time.Time
+}
+
+// iapKeySetTTL bounds how long a fetched key is trusted before iapKeySet
+// re-fetches the JWK set, so a rotated or revoked IAP signing key is
+// eventually honored without a restart.
+const iapKeySetTTL = time.Hour
+
+var defaultIAPKeySet = &iapKeySet{}
+
+func (ks *iapKeySet) key(keyID string) (*ecdsa.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if k, ok := ks.keys[keyID]; ok && time.Since(ks.fetched) < iapKeySetTTL {
+		return k, nil
+	}
+
+	keys, err := fetchIAPKeys()
+	if err != nil {
+		return nil, err
+	}
+	ks.keys = keys
+	ks.fetched = time.Now()
+
+	k, ok := ks.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("snippets: no IAP signing key for kid %q", keyID)
+	}
+	return k, nil
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func fetchIAPKeys() (map[string]*ecdsa.PublicKey, error) {
+	resp, err := http.Get(iapPublicKeyURL)
+	if err != nil {
+		return nil, fmt.Errorf("snippets: fetching IAP public keys: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("snippets: decoding IAP public keys: %w", err)
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Crv != "P-256" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			continue
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+	}
+	return keys, nil
+}
+
+// verifyIAPAssertion validates assertion, the value of the
+// X-Goog-IAP-JWT-Assertion header IAP signs and attaches to every request it
+// proxies, and returns the email of the authenticated caller. It checks the
+// signature against IAP's public keys, that iss is iapIssuer, and that aud
+// matches the configured iapAudience, so (unlike
+// X-Goog-Authenticated-User-Email) the result can't be forged by a client
+// that talks to this service directly.
+func verifyIAPAssertion(assertion string) (string, error) {
+	aud := iapAudience()
+	if aud == "" {
+		return "", fmt.Errorf("snippets: SNIPPETS_IAP_AUDIENCE is not configured")
+	}
+
+	token, err := jwt.Parse(assertion, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return defaultIAPKeySet.key(kid)
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithIssuer(iapIssuer), jwt.WithAudience(aud))
+	if err != nil {
+		return "", fmt.Errorf("snippets: invalid IAP assertion: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", fmt.Errorf("snippets: invalid IAP assertion claims")
+	}
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return "", fmt.Errorf("snippets: IAP assertion has no email claim")
+	}
+	return email, nil
+}
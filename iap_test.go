@@ -0,0 +1,125 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestIAPAssertion starts an httptest.Server standing in for
+// iapPublicKeyURL and returns a JWT signed as IAP would sign one asserting
+// email, for aud. t.Cleanup restores iapPublicKeyURL and the key cache.
+func newTestIAPAssertion(t *testing.T, aud, email string) string {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const kid = "test-key"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		x := base64.RawURLEncoding.EncodeToString(priv.X.Bytes())
+		y := base64.RawURLEncoding.EncodeToString(priv.Y.Bytes())
+		fmt.Fprintf(w, `{"keys":[{"kid":%q,"crv":"P-256","x":%q,"y":%q}]}`, kid, x, y)
+	}))
+	t.Cleanup(srv.Close)
+
+	origURL := iapPublicKeyURL
+	iapPublicKeyURL = srv.URL
+	t.Cleanup(func() { iapPublicKeyURL = origURL })
+
+	origKeySet := defaultIAPKeySet
+	defaultIAPKeySet = &iapKeySet{}
+	t.Cleanup(func() { defaultIAPKeySet = origKeySet })
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss":   iapIssuer,
+		"aud":   aud,
+		"email": email,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyIAPAssertion(t *testing.T) {
+	const aud = "/projects/123456789/global/backendServices/987654321"
+	t.Setenv("SNIPPETS_IAP_AUDIENCE", aud)
+	assertion := newTestIAPAssertion(t, aud, "admin@example.com")
+
+	email, err := verifyIAPAssertion(assertion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := email, "admin@example.com"; got != want {
+		t.Fatalf("email: got %q, want %q", got, want)
+	}
+}
+
+func TestVerifyIAPAssertionRejectsWrongAudience(t *testing.T) {
+	const aud = "/projects/123456789/global/backendServices/987654321"
+	t.Setenv("SNIPPETS_IAP_AUDIENCE", aud)
+	assertion := newTestIAPAssertion(t, "/projects/123456789/global/backendServices/other", "admin@example.com")
+
+	if _, err := verifyIAPAssertion(assertion); err == nil {
+		t.Fatal("expected an audience mismatch to be rejected")
+	}
+}
+
+func TestVerifyIAPAssertionRejectsForgedHeader(t *testing.T) {
+	const aud = "/projects/123456789/global/backendServices/987654321"
+	t.Setenv("SNIPPETS_IAP_AUDIENCE", aud)
+	// Stand up the JWKS server so a signature failure, not a network error,
+	// is what's being exercised.
+	newTestIAPAssertion(t, aud, "admin@example.com")
+
+	// Forge an otherwise valid assertion, signed with a key IAP doesn't hold
+	// but naming IAP's key ID.
+	forger, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss":   iapIssuer,
+		"aud":   aud,
+		"email": "admin@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "test-key"
+	forged, err := token.SignedString(forger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifyIAPAssertion(forged); err == nil {
+		t.Fatal("expected a forged assertion to be rejected")
+	}
+}
@@ -12,26 +12,32 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package snippets implements the storage backend for the Ebiten Playground:
+// an HTTP API to save and load content-addressed snippets, plus an /admin/
+// subtree to audit and take down stored content.
 package snippets
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
-	"golang.org/x/net/context" // Use this until Go 1.9's type alias is available
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/memcache"
+	"cloud.google.com/go/datastore"
+	"github.com/redis/go-redis/v9"
 )
 
-const (
-	maxContentSizeInBytes = 10 * 1024
-	kindName              = "Snippet"
-)
+// maxContentSizeInBytes matches the Go playground's maxSnippetSize. It's the
+// limit on the uncompressed payload; compression keeps stored entities well
+// under the Datastore 1 MiB entity limit.
+const maxContentSizeInBytes = 64 * 1024
 
 const testForm = `<!DOCTYPE html>
 <script>
@@ -54,49 +60,26 @@ window.addEventListener('load', _ => {
 <button id="submit-button">Submit</button>
 `
 
-type Snippet struct {
-	CreatedAt time.Time
-	Content   []uint8 `datastore:",noindex"`
+// runningLocally reports whether the process looks like a local development
+// run rather than a deployment, by checking for K_SERVICE, which Cloud Run
+// sets on every deployed revision. It replaces the App Engine SDK's
+// appengine.IsDevAppServer() check now that this package no longer runs
+// under dev_appserver.
+func runningLocally() bool {
+	return os.Getenv("K_SERVICE") == ""
 }
 
-func getSnippetFromKey(ctx context.Context, keyName string) (*Snippet, error) {
-	s := &Snippet{}
-	if _, err := memcache.Gob.Get(ctx, keyName, s); err == nil {
-		return s, nil
-	} else if err != memcache.ErrCacheMiss {
-		return nil, err
-	}
-
-	key := datastore.NewKey(ctx, kindName, keyName, 0, nil)
-	if err := datastore.Get(ctx, key, s); err != nil {
-		if err == datastore.ErrNoSuchEntity {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	item := &memcache.Item{
-		Key:    keyName,
-		Object: s,
-	}
-	if err := memcache.Gob.Set(ctx, item); err != nil {
-		return nil, err
-	}
-
-	return s, nil
-}
-
-func getSnippets(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+func getSnippets(ctx context.Context, store Store, w http.ResponseWriter, r *http.Request) {
 	if len(r.URL.Path) > 1 {
 		keyName := r.URL.Path[1:]
 
-		s, err := getSnippetFromKey(ctx, keyName)
+		s, ok, err := getSnippetFromKey(ctx, store, keyName)
 		if err != nil {
 			msg := fmt.Sprintf("Could not retrieve data: %v", err)
 			http.Error(w, msg, http.StatusInternalServerError)
 			return
 		}
-		if s == nil {
+		if !ok {
 			http.NotFound(w, r)
 			return
 		}
@@ -106,11 +89,33 @@ func getSnippets(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 		// While the data is immutable, the data might be deleted for a security reason.
 		// Set cache's life time not too long time (max-age = 1 hour).
 		w.Header().Set("Cache-Control", "public, max-age=3600")
-		w.Write(s.Content)
+
+		// Whether this response is gzip-encoded depends on Accept-Encoding,
+		// so a shared cache must key on it too; otherwise it could serve one
+		// client's gzip response to a client that never asked for it.
+		if s.Encoding == encodingGzip {
+			w.Header().Set("Vary", "Accept-Encoding")
+		}
+
+		// If the stored bytes are already gzip-compressed and the client
+		// accepts gzip, skip decompression and stream them straight through.
+		if s.Encoding == encodingGzip && acceptsGzip(r) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(s.Content)
+			return
+		}
+
+		content, err := decompressContent(s.Content, s.Encoding)
+		if err != nil {
+			msg := fmt.Sprintf("Could not decode data: %v", err)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		w.Write(content)
 		return
 	}
 
-	if appengine.IsDevAppServer() {
+	if runningLocally() {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Write([]uint8(testForm))
 		return
@@ -119,7 +124,7 @@ func getSnippets(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	http.NotFound(w, r)
 }
 
-func postSnippets(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+func postSnippets(ctx context.Context, store Store, w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
@@ -138,49 +143,29 @@ func postSnippets(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Just use characters in [0-9a-f] for IDs so that they can be in subdomain.
-	keyName := fmt.Sprintf("%x", sha256.Sum256(content))
-	key := datastore.NewKey(ctx, kindName, keyName, 0, nil)
+	sum := sha256.Sum256(content)
+	fullKey := base64.URLEncoding.EncodeToString(sum[:])
 
-	created := false
-	s := &Snippet{}
-	if err := datastore.RunInTransaction(ctx, func(ctx context.Context) error {
-		// Search existing one
-		err := datastore.Get(ctx, key, s)
-		if err == nil {
-			return nil
-		}
-		if err != datastore.ErrNoSuchEntity {
-			return err
-		}
+	stored, encoding, err := compressForStorage(content)
+	if err != nil {
+		msg := fmt.Sprintf("Could not store the request body: %v", err)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	s := &Snippet{
+		CreatedAt: time.Now(),
+		Content:   stored,
+		Encoding:  encoding,
+	}
 
-		s = &Snippet{
-			CreatedAt: time.Now(),
-			Content:   content,
-		}
-		k := datastore.NewKey(ctx, kindName, keyName, 0, nil)
-		key, err = datastore.Put(ctx, k, s)
-		if err != nil {
-			return err
-		}
-		created = true
-		return nil
-	}, nil); err != nil {
+	keyName, created, err := allocateKey(ctx, store, fullKey, content, s)
+	if err != nil {
 		msg := fmt.Sprintf("Could not store the request body: %v", err)
 		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
 
 	if created {
-		if err := memcache.Gob.Set(ctx, &memcache.Item{
-			Key:    keyName,
-			Object: s,
-		}); err != nil {
-			msg := fmt.Sprintf("Could not store the request body: %v", err)
-			http.Error(w, msg, http.StatusBadRequest)
-			return
-		}
-
 		w.WriteHeader(http.StatusCreated)
 	} else {
 		w.WriteHeader(http.StatusOK)
@@ -188,21 +173,110 @@ func postSnippets(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, keyName)
 }
 
-func handleSnippets(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	ctx := appengine.NewContext(r)
-	switch r.Method {
-	case http.MethodHead, http.MethodGet:
-		getSnippets(ctx, w, r)
-	case http.MethodPost:
-		postSnippets(ctx, w, r)
-	default:
-		s := http.StatusMethodNotAllowed
-		http.Error(w, http.StatusText(s), s)
+// minKeyLength is the length of the base64url-encoded SHA-256 prefix used
+// as a snippet's ID, matching the Go playground's Snippet.Id().
+const minKeyLength = 11
+
+// maxKeyExtensions bounds how many times allocateKey will lengthen the
+// prefix to resolve a collision before giving up.
+const maxKeyExtensions = 8
+
+// allocateKey stores s under the ID to use for content, given fullKey, the
+// base64url encoding of content's SHA-256 sum. It starts from the short
+// prefix used for new IDs and, if that prefix is already taken by different
+// content, extends it a character at a time until it claims a free ID or
+// finds the entity already stored for this exact content. created reports
+// whether s was newly stored under the returned key; when it's false, an
+// identical snippet already existed there and the caller should reuse it
+// instead of having written a new one.
+//
+// Each prefix is claimed with store.CreateSnippet rather than a separate
+// get-then-put, so the check of whether a prefix is free and the write that
+// claims it happen atomically: two concurrent POSTs that land on the same
+// prefix can't both see it as free and then overwrite each other's content.
+func allocateKey(ctx context.Context, store Store, fullKey string, content []byte, s *Snippet) (string, bool, error) {
+	length := minKeyLength
+	for i := 0; i <= maxKeyExtensions; i++ {
+		if length > len(fullKey) {
+			length = len(fullKey)
+		}
+		keyName := fullKey[:length]
+
+		created, existing, err := store.CreateSnippet(ctx, keyName, s)
+		if err != nil {
+			return "", false, err
+		}
+		if created {
+			return keyName, true, nil
+		}
+
+		existingContent, err := decompressContent(existing.Content, existing.Encoding)
+		if err != nil {
+			return "", false, err
+		}
+		if bytes.Equal(existingContent, content) {
+			return keyName, false, nil
+		}
+
+		if length == len(fullKey) {
+			break
+		}
+		length++
+	}
+	return "", false, fmt.Errorf("snippets: could not allocate an id for this content after %d attempts", maxKeyExtensions+1)
+}
+
+// getSnippetFromKey loads the snippet stored under keyName. No special
+// handling is needed to support both the short base64url IDs minted by
+// allocateKey and the long hex-encoded SHA-256 IDs used before collision-safe
+// truncation was introduced: Store treats keyName as an opaque string, so
+// entities written under the old long keys remain reachable by requesting
+// that same long key.
+func getSnippetFromKey(ctx context.Context, store Store, keyName string) (*Snippet, bool, error) {
+	s := &Snippet{}
+	ok, err := store.GetSnippet(ctx, keyName, s)
+	if err != nil {
+		return nil, false, err
+	}
+	return s, ok, nil
+}
+
+// acceptsGzip reports whether r's Accept-Encoding header lists gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, e := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(e) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// handleSnippets returns an http.HandlerFunc that serves the snippets API
+// against store, so the handler can be wired up to a real backend in
+// production and to a fake one (e.g. NewMemoryStore) in tests.
+func handleSnippets(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		ctx := r.Context()
+		switch r.Method {
+		case http.MethodHead, http.MethodGet:
+			getSnippets(ctx, store, w, r)
+		case http.MethodPost:
+			postSnippets(ctx, store, w, r)
+		default:
+			s := http.StatusMethodNotAllowed
+			http.Error(w, http.StatusText(s), s)
+		}
 	}
 }
 
-func init() {
-	http.HandleFunc("/", handleSnippets)
+// RegisterHandlers wires the snippets API and the admin subtree up to mux,
+// backed by ds and cache. This replaces the init()-based registration used
+// when this package only ran embedded in an App Engine app: cmd/snippets-server
+// now constructs ds and cache explicitly and calls RegisterHandlers from main.
+func RegisterHandlers(mux *http.ServeMux, ds *datastore.Client, cache *redis.Client) {
+	store := NewCloudStore(ds, cache)
+	mux.Handle("/", handleSnippets(store))
+	mux.Handle("/admin/", handleAdmin(store))
 }
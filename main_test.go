@@ -0,0 +1,218 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostThenGetSnippet(t *testing.T) {
+	h := handleSnippets(NewMemoryStore())
+
+	const content = "package main\n\nfunc main() {}\n"
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(content))
+	h(w, r)
+
+	if got, want := w.Code, 201; got != want {
+		t.Fatalf("POST status: got %d, want %d", got, want)
+	}
+	key := w.Body.String()
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/"+key, nil)
+	h(w, r)
+
+	if got, want := w.Code, 200; got != want {
+		t.Fatalf("GET status: got %d, want %d", got, want)
+	}
+	if got, want := w.Body.String(), content; got != want {
+		t.Fatalf("GET body: got %q, want %q", got, want)
+	}
+}
+
+func TestPostSameContentTwiceReturnsSameKey(t *testing.T) {
+	h := handleSnippets(NewMemoryStore())
+
+	const content = "same content"
+
+	w1 := httptest.NewRecorder()
+	h(w1, httptest.NewRequest("POST", "/", strings.NewReader(content)))
+	if got, want := w1.Code, 201; got != want {
+		t.Fatalf("first POST status: got %d, want %d", got, want)
+	}
+
+	w2 := httptest.NewRecorder()
+	h(w2, httptest.NewRequest("POST", "/", strings.NewReader(content)))
+	if got, want := w2.Code, 200; got != want {
+		t.Fatalf("second POST status: got %d, want %d", got, want)
+	}
+	if got, want := w2.Body.String(), w1.Body.String(); got != want {
+		t.Fatalf("second POST key: got %q, want %q", got, want)
+	}
+}
+
+func TestPostThenGetLargeSnippetIsCompressed(t *testing.T) {
+	h := handleSnippets(NewMemoryStore())
+
+	content := strings.Repeat("large snippet content ", 200)
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/", strings.NewReader(content)))
+	key := w.Body.String()
+
+	// A client that doesn't advertise gzip support gets decompressed bytes.
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/"+key, nil)
+	h(w, r)
+	if got, want := w.Body.String(), content; got != want {
+		t.Fatalf("GET body: got %q, want %q", got, want)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("Content-Encoding: got %q, want empty", got)
+	}
+	if got, want := w.Header().Get("Vary"), "Accept-Encoding"; got != want {
+		t.Fatalf("Vary: got %q, want %q", got, want)
+	}
+
+	// A client that advertises gzip support gets the compressed bytes
+	// straight through, along with a matching Content-Encoding.
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/"+key, nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	h(w, r)
+	if got, want := w.Header().Get("Content-Encoding"), "gzip"; got != want {
+		t.Fatalf("Content-Encoding: got %q, want %q", got, want)
+	}
+	if got, want := w.Header().Get("Vary"), "Accept-Encoding"; got != want {
+		t.Fatalf("Vary: got %q, want %q", got, want)
+	}
+	if w.Body.String() == content {
+		t.Fatal("expected gzip-encoded body to differ from the original content")
+	}
+}
+
+func TestGetUnknownSnippet(t *testing.T) {
+	h := handleSnippets(NewMemoryStore())
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/deadbeef", nil))
+
+	if got, want := w.Code, 404; got != want {
+		t.Fatalf("GET status: got %d, want %d", got, want)
+	}
+}
+
+func TestAllocateKeyExtendsPrefixOnCollision(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	content := []byte("the real content")
+	sum := sha256.Sum256(content)
+	fullKey := base64.URLEncoding.EncodeToString(sum[:])
+
+	// Plant an unrelated snippet under the short prefix so allocateKey has
+	// to extend it to find a free (or matching) ID.
+	if err := store.PutSnippet(ctx, fullKey[:minKeyLength], &Snippet{Content: []byte("someone else's content")}); err != nil {
+		t.Fatal(err)
+	}
+
+	keyName, created, err := allocateKey(ctx, store, fullKey, content, &Snippet{Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatal("expected allocateKey to create a new snippet for this content")
+	}
+	if got, want := keyName, fullKey[:minKeyLength+1]; got != want {
+		t.Fatalf("keyName: got %q, want %q", got, want)
+	}
+}
+
+func TestAllocateKeyReusesIdenticalContent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	content := []byte("identical content")
+	sum := sha256.Sum256(content)
+	fullKey := base64.URLEncoding.EncodeToString(sum[:])
+
+	first, created, err := allocateKey(ctx, store, fullKey, content, &Snippet{Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatal("expected allocateKey to create a new snippet on first allocation")
+	}
+
+	second, created, err := allocateKey(ctx, store, fullKey, content, &Snippet{Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatal("expected allocateKey to find the existing identical snippet")
+	}
+	if second != first {
+		t.Fatalf("keyName: got %q, want %q", second, first)
+	}
+}
+
+func TestAllocateKeyDoesNotOverwriteConcurrentWinner(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	content := []byte("mine")
+	sum := sha256.Sum256(content)
+	fullKey := base64.URLEncoding.EncodeToString(sum[:])
+	keyName := fullKey[:minKeyLength]
+
+	// Simulate a concurrent POST claiming the same prefix for different
+	// content between this call's allocateKey check and write by having it
+	// already sitting in the store before allocateKey runs.
+	other := []byte("someone else's")
+	if created, _, err := store.CreateSnippet(ctx, keyName, &Snippet{Content: other}); err != nil {
+		t.Fatal(err)
+	} else if !created {
+		t.Fatal("expected to create the concurrent winner's snippet")
+	}
+
+	got, created, err := allocateKey(ctx, store, fullKey, content, &Snippet{Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatal("expected allocateKey to create a new entity under the extended prefix")
+	}
+	if got == keyName {
+		t.Fatalf("keyName: got %q, want the prefix extended past the collision", got)
+	}
+
+	var s Snippet
+	if ok, err := store.GetSnippet(ctx, keyName, &s); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected the concurrent winner's entity to still be present")
+	}
+	if !bytes.Equal(s.Content, other) {
+		t.Fatalf("Content under %q: got %q, want %q (got overwritten)", keyName, s.Content, other)
+	}
+}
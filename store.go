@@ -0,0 +1,72 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"context"
+	"time"
+)
+
+const kindName = "Snippet"
+
+// Snippet is a single piece of content submitted by a client, keyed by a
+// prefix of the SHA-256 hash of its uncompressed content.
+type Snippet struct {
+	CreatedAt time.Time
+	Content   []uint8 `datastore:",noindex"`
+
+	// Encoding names the compression applied to Content before it was
+	// stored ("gzip" or "zstd"), or is empty if Content is stored raw. Old
+	// entities written before compression was introduced have an empty
+	// Encoding and are read back as-is.
+	Encoding string `datastore:",noindex"`
+}
+
+// SnippetMeta is the metadata of a stored snippet, without its Content, as
+// returned by Store.ListSnippets.
+type SnippetMeta struct {
+	Key       string
+	CreatedAt time.Time
+	Size      int
+}
+
+// Store abstracts the persistence of snippets, analogous to the Go
+// playground's store abstraction. It lets handleSnippets be exercised with
+// httptest without a live backend.
+type Store interface {
+	// PutSnippet stores s under id, overwriting any existing entity.
+	PutSnippet(ctx context.Context, id string, s *Snippet) error
+
+	// CreateSnippet atomically stores s under id if, and only if, no entity
+	// is stored there yet. created reports whether s was stored. If an
+	// entity already exists under id, CreateSnippet leaves it untouched and
+	// returns it as existing instead, so the caller can decide whether to
+	// reuse it (e.g. its Content matches s's) without racing a separate
+	// writer that might claim id in between a read and a write.
+	CreateSnippet(ctx context.Context, id string, s *Snippet) (created bool, existing *Snippet, err error)
+
+	// GetSnippet loads the snippet stored under id into s. The returned bool
+	// reports whether an entity was found.
+	GetSnippet(ctx context.Context, id string, s *Snippet) (bool, error)
+
+	// DeleteSnippet removes the snippet stored under id, if any.
+	DeleteSnippet(ctx context.Context, id string) error
+
+	// ListSnippets returns a page of snippet metadata ordered by CreatedAt,
+	// starting after cursor (the empty string starts from the beginning), and
+	// containing at most limit entries. The returned cursor, if non-empty,
+	// can be passed to the next call to ListSnippets to fetch the next page.
+	ListSnippets(ctx context.Context, cursor string, limit int) (metas []SnippetMeta, nextCursor string, err error)
+}
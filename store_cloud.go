@@ -0,0 +1,178 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+
+	"cloud.google.com/go/datastore"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/api/iterator"
+)
+
+// cloudStore persists snippets in Cloud Datastore and read-through caches
+// them in Redis (e.g. Cloud Memorystore), mirroring the Datastore+Memcache
+// pairing the classic App Engine driver used. It doesn't require the App
+// Engine runtime, so it can back this package from Cloud Run, GKE, or any
+// other Go binary.
+type cloudStore struct {
+	ds    *datastore.Client
+	cache *redis.Client
+}
+
+// NewCloudStore returns a Store backed by Cloud Datastore, cached in cache.
+func NewCloudStore(ds *datastore.Client, cache *redis.Client) Store {
+	return &cloudStore{ds: ds, cache: cache}
+}
+
+func (s *cloudStore) PutSnippet(ctx context.Context, id string, snip *Snippet) error {
+	key := datastore.NameKey(kindName, id, nil)
+	if _, err := s.ds.Put(ctx, key, snip); err != nil {
+		return err
+	}
+	return s.cacheSet(ctx, id, snip)
+}
+
+func (s *cloudStore) CreateSnippet(ctx context.Context, id string, snip *Snippet) (bool, *Snippet, error) {
+	key := datastore.NameKey(kindName, id, nil)
+
+	var existing *Snippet
+	created := false
+	_, err := s.ds.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		existing = nil
+		created = false
+
+		var stored Snippet
+		switch err := tx.Get(key, &stored); err {
+		case nil:
+			existing = &stored
+			return nil
+		case datastore.ErrNoSuchEntity:
+			// Fall through to create below.
+		default:
+			return err
+		}
+
+		if _, err := tx.Put(key, snip); err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	if created {
+		if err := s.cacheSet(ctx, id, snip); err != nil {
+			return false, nil, err
+		}
+	}
+	return created, existing, nil
+}
+
+func (s *cloudStore) GetSnippet(ctx context.Context, id string, snip *Snippet) (bool, error) {
+	if ok, err := s.cacheGet(ctx, id, snip); err != nil {
+		return false, err
+	} else if ok {
+		return true, nil
+	}
+
+	key := datastore.NameKey(kindName, id, nil)
+	if err := s.ds.Get(ctx, key, snip); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := s.cacheSet(ctx, id, snip); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *cloudStore) DeleteSnippet(ctx context.Context, id string) error {
+	key := datastore.NameKey(kindName, id, nil)
+	if err := s.ds.Delete(ctx, key); err != nil && err != datastore.ErrNoSuchEntity {
+		return err
+	}
+	if err := s.cache.Del(ctx, id).Err(); err != nil && err != redis.Nil {
+		return err
+	}
+	return nil
+}
+
+func (s *cloudStore) ListSnippets(ctx context.Context, cursor string, limit int) ([]SnippetMeta, string, error) {
+	q := datastore.NewQuery(kindName).Order("CreatedAt").Limit(limit)
+	if cursor != "" {
+		c, err := datastore.DecodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		q = q.Start(c)
+	}
+
+	var metas []SnippetMeta
+	it := s.ds.Run(ctx, q)
+	for {
+		var snip Snippet
+		key, err := it.Next(&snip)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		metas = append(metas, SnippetMeta{
+			Key:       key.Name,
+			CreatedAt: snip.CreatedAt,
+			Size:      len(snip.Content),
+		})
+	}
+
+	var nextCursor string
+	if len(metas) == limit {
+		c, err := it.Cursor()
+		if err != nil {
+			return nil, "", err
+		}
+		nextCursor = c.String()
+	}
+	return metas, nextCursor, nil
+}
+
+func (s *cloudStore) cacheGet(ctx context.Context, id string, snip *Snippet) (bool, error) {
+	b, err := s.cache.Get(ctx, id).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(snip); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *cloudStore) cacheSet(ctx context.Context, id string, snip *Snippet) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snip); err != nil {
+		return err
+	}
+	return s.cache.Set(ctx, id, buf.Bytes(), 0).Err()
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 The Ebiten Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snippets
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// memoryStore is an in-memory Store, suitable for unit tests and local runs
+// that don't have a real Datastore/cache backend.
+type memoryStore struct {
+	mu       sync.Mutex
+	snippets map[string]Snippet
+}
+
+// NewMemoryStore returns a Store backed by a process-local map.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		snippets: map[string]Snippet{},
+	}
+}
+
+func (m *memoryStore) PutSnippet(ctx context.Context, id string, s *Snippet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snippets[id] = *s
+	return nil
+}
+
+func (m *memoryStore) CreateSnippet(ctx context.Context, id string, s *Snippet) (bool, *Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stored, ok := m.snippets[id]; ok {
+		existing := stored
+		return false, &existing, nil
+	}
+	m.snippets[id] = *s
+	return true, nil, nil
+}
+
+func (m *memoryStore) GetSnippet(ctx context.Context, id string, s *Snippet) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.snippets[id]
+	if !ok {
+		return false, nil
+	}
+	*s = stored
+	return true, nil
+}
+
+func (m *memoryStore) DeleteSnippet(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.snippets, id)
+	return nil
+}
+
+// ListSnippets orders snippets by CreatedAt and encodes cursor as a plain
+// decimal offset into that order; good enough for tests and local runs.
+func (m *memoryStore) ListSnippets(ctx context.Context, cursor string, limit int) ([]SnippetMeta, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metas := make([]SnippetMeta, 0, len(m.snippets))
+	for key, s := range m.snippets {
+		metas = append(metas, SnippetMeta{
+			Key:       key,
+			CreatedAt: s.CreatedAt,
+			Size:      len(s.Content),
+		})
+	}
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].CreatedAt.Before(metas[j].CreatedAt)
+	})
+
+	offset := 0
+	if cursor != "" {
+		o, err := strconv.Atoi(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		offset = o
+	}
+	if offset > len(metas) {
+		offset = len(metas)
+	}
+	metas = metas[offset:]
+
+	var nextCursor string
+	if len(metas) > limit {
+		metas = metas[:limit]
+		nextCursor = strconv.Itoa(offset + limit)
+	}
+	return metas, nextCursor, nil
+}